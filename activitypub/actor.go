@@ -0,0 +1,52 @@
+package activitypub
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// localActor is one of this instance's ActivityPub actors — one per
+// configured upstream source, e.g. "medium" for @medium@readium.example.com.
+type localActor struct {
+	name       string
+	id         string // e.g. "https://readium.example.com/ap/actors/medium"
+	privateKey *rsa.PrivateKey
+}
+
+func newLocalActor(domain, name string, key *rsa.PrivateKey) *localActor {
+	return &localActor{
+		name:       name,
+		id:         fmt.Sprintf("https://%s/ap/actors/%s", domain, name),
+		privateKey: key,
+	}
+}
+
+// document renders the actor as the AS2 document served at a.id.
+func (a *localActor) document() Actor {
+	der, err := x509.MarshalPKIXPublicKey(&a.privateKey.PublicKey)
+	if err != nil {
+		// The key came from rsa.GenerateKey or a PKCS1 parse; marshaling
+		// its public half back out cannot fail.
+		panic(fmt.Sprintf("activitypub: marshal public key for %q: %v", a.name, err))
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	return Actor{
+		Context:           context,
+		ID:                a.id,
+		Type:              "Service",
+		PreferredUsername: a.name,
+		Name:              a.name + " (via readium)",
+		Summary:           "Readability-extracted articles from this source, bridged to the Fediverse.",
+		Inbox:             a.id + "/inbox",
+		Outbox:            a.id + "/outbox",
+		Followers:         a.id + "/followers",
+		PublicKey: PublicKey{
+			ID:           a.id + "#main-key",
+			Owner:        a.id,
+			PublicKeyPem: string(pubPEM),
+		},
+	}
+}
@@ -0,0 +1,173 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/husio/readium/readability"
+)
+
+// Bridge turns cached extractions into an ActivityPub feed: one actor per
+// configured upstream source, each followable from Mastodon/Pleroma and
+// notified with a signed Create activity whenever a new article for that
+// source is cached.
+type Bridge struct {
+	domain string
+	client *http.Client
+	store  *Store
+	actors map[string]*localActor
+}
+
+// NewBridge opens (or creates) the follower/key store at dbPath and
+// provisions one actor per name in sourceNames. domain (e.g.
+// "readium.example.com") is used for webfinger resolution and actor IDs.
+func NewBridge(domain, dbPath string, sourceNames []string, client *http.Client) (*Bridge, error) {
+	store, err := OpenStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{domain: domain, client: safeClient(client), store: store, actors: map[string]*localActor{}}
+	for _, name := range sourceNames {
+		key, err := store.LoadOrCreateKey(name)
+		if err != nil {
+			return nil, fmt.Errorf("actor %q: %w", name, err)
+		}
+		b.actors[name] = newLocalActor(domain, name, key)
+	}
+	return b, nil
+}
+
+// Handler mounts the bridge's webfinger, host-meta, actor, inbox and
+// outbox routes. It owns the well-known discovery paths and the actor
+// paths, so register it under "/.well-known/" and "/ap/" alongside the
+// rest of the proxy.
+func (b *Bridge) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/webfinger", b.serveWebfinger)
+	mux.HandleFunc("/.well-known/host-meta", b.serveHostMeta)
+	mux.HandleFunc("/ap/actors/", b.serveActor)
+	return mux
+}
+
+func (b *Bridge) serveActor(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/ap/actors/")
+	name, sub, _ := strings.Cut(rest, "/")
+	actor, ok := b.actors[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch sub {
+	case "":
+		w.Header().Set("Content-Type", "application/activity+json")
+		json.NewEncoder(w).Encode(actor.document())
+	case "inbox":
+		b.serveInbox(name, w, r)
+	case "outbox":
+		b.serveOutbox(name, w, r)
+	case "followers":
+		b.serveFollowers(name, w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Publish builds a Create(Article) for sourceName's actor and delivers it
+// to every follower. It's a no-op if sourceName has no configured actor.
+// Delivery happens per follower but failures are only logged — a slow or
+// dead follower inbox must never block the page the proxy is serving, so
+// callers should invoke Publish from its own goroutine.
+func (b *Bridge) Publish(sourceName string, art *readability.Article, pageURL string) {
+	actor, ok := b.actors[sourceName]
+	if !ok {
+		return
+	}
+
+	published := art.Published
+	if published.IsZero() {
+		published = time.Now().UTC()
+	}
+	obj := Article{
+		ID:           fmt.Sprintf("%s/articles/%s", actor.id, hashID(pageURL)),
+		Type:         "Article",
+		Name:         art.Title,
+		Content:      art.HTML,
+		URL:          pageURL,
+		Published:    published.Format(time.RFC3339),
+		AttributedTo: actor.id,
+	}
+	create := Activity{
+		Context: context,
+		ID:      fmt.Sprintf("%s/creates/%s", actor.id, randomID()),
+		Type:    "Create",
+		Actor:   actor.id,
+		Object:  obj,
+		To:      []string{"https://www.w3.org/ns/activitystreams#Public"},
+	}
+
+	if err := b.store.AddOutboxItem(sourceName, create.ID, published.Format(time.RFC3339), create); err != nil {
+		log.Printf("activitypub: record outbox item for %s: %v", sourceName, err)
+	}
+
+	followers, err := b.store.Followers(sourceName)
+	if err != nil {
+		log.Printf("activitypub: list followers for %s: %v", sourceName, err)
+		return
+	}
+	for _, f := range followers {
+		if err := b.deliver(actor, f.Inbox, create); err != nil {
+			log.Printf("activitypub: deliver to %s: %v", f.ID, err)
+		}
+	}
+}
+
+// deliver signs activity with actor's key and POSTs it to inbox.
+func (b *Bridge) deliver(actor *localActor, inbox string, activity any) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("marshal activity: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build delivery request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+	if u, err := url.Parse(inbox); err == nil {
+		req.Host = u.Host
+	}
+	if err := signRequest(req, actor.id+"#main-key", actor.privateKey, body); err != nil {
+		return err
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("inbox responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func randomID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func hashID(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,68 @@
+package activitypub
+
+// collections.go renders the AS2 OrderedCollections backing an actor's
+// outbox and followers list.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// outboxPageSize bounds how many recent activities an actor's outbox
+// serves, since nothing in this bridge needs real pagination yet.
+const outboxPageSize = 20
+
+func (b *Bridge) serveOutbox(name string, w http.ResponseWriter, r *http.Request) {
+	total, err := b.store.OutboxCount(name)
+	if err != nil {
+		log.Printf("activitypub: outbox count for %s: %v", name, err)
+	}
+	items, err := b.store.RecentOutboxItems(name, outboxPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	actor := b.actors[name]
+	ordered := make([]any, len(items))
+	for i, item := range items {
+		ordered[i] = json.RawMessage(item)
+	}
+
+	w.Header().Set("Content-Type", "application/activity+json")
+	json.NewEncoder(w).Encode(orderedCollection{
+		Context:      context,
+		ID:           actor.id + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   total,
+		OrderedItems: ordered,
+	})
+}
+
+func (b *Bridge) serveFollowers(name string, w http.ResponseWriter, r *http.Request) {
+	total, err := b.store.FollowerCount(name)
+	if err != nil {
+		log.Printf("activitypub: follower count for %s: %v", name, err)
+	}
+	followers, err := b.store.Followers(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	actor := b.actors[name]
+	ordered := make([]any, len(followers))
+	for i, f := range followers {
+		ordered[i] = f.ID
+	}
+
+	w.Header().Set("Content-Type", "application/activity+json")
+	json.NewEncoder(w).Encode(orderedCollection{
+		Context:      context,
+		ID:           actor.id + "/followers",
+		Type:         "OrderedCollection",
+		TotalItems:   total,
+		OrderedItems: ordered,
+	})
+}
@@ -0,0 +1,126 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// inboxActivity is the subset of an incoming activity's fields this
+// bridge understands (Follow and Undo(Follow)).
+type inboxActivity struct {
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// serveInbox handles Follow and Undo(Follow) deliveries for the actor
+// named by name. Anything else is accepted but otherwise ignored, since
+// this bridge only ever needs to track who's following it.
+func (b *Bridge) serveInbox(name string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	signerID, err := verifyRequest(r, body, b.client)
+	if err != nil {
+		log.Printf("activitypub: signature verification failed: %v", err)
+		http.Error(w, "signature verification failed", http.StatusUnauthorized)
+		return
+	}
+
+	var act inboxActivity
+	if err := json.Unmarshal(body, &act); err != nil {
+		http.Error(w, "malformed activity", http.StatusBadRequest)
+		return
+	}
+	if act.Actor != signerID {
+		http.Error(w, "actor does not match signature", http.StatusUnauthorized)
+		return
+	}
+
+	actor := b.actors[name]
+
+	switch act.Type {
+	case "Follow":
+		inbox, err := b.resolveInbox(signerID)
+		if err != nil {
+			log.Printf("activitypub: resolve inbox for follower %s: %v", signerID, err)
+			http.Error(w, "could not resolve follower inbox", http.StatusBadGateway)
+			return
+		}
+		if err := b.store.AddFollower(name, signerID, inbox); err != nil {
+			log.Printf("activitypub: store follower %s: %v", signerID, err)
+		}
+		if err := b.deliverAccept(actor, act, inbox); err != nil {
+			log.Printf("activitypub: deliver Accept to %s: %v", signerID, err)
+		}
+	case "Undo":
+		var undone struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(act.Object, &undone); err == nil && undone.Type != "" && undone.Type != "Follow" {
+			break
+		}
+		if err := b.store.RemoveFollower(name, signerID); err != nil {
+			log.Printf("activitypub: remove follower %s: %v", signerID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resolveInbox fetches actorID's actor document and returns its inbox URL.
+func (b *Bridge) resolveInbox(actorID string) (string, error) {
+	if _, err := validateFetchURL(actorID); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, actorID, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var remote Actor
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return "", err
+	}
+	if remote.Inbox == "" {
+		return "", fmt.Errorf("actor %s has no inbox", actorID)
+	}
+	return remote.Inbox, nil
+}
+
+// deliverAccept replies to a Follow with a signed Accept, as required
+// before Mastodon/Pleroma will show the follow as confirmed.
+func (b *Bridge) deliverAccept(actor *localActor, follow inboxActivity, followerInbox string) error {
+	accept := Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		ID:      fmt.Sprintf("%s/accepts/%s", actor.id, randomID()),
+		Type:    "Accept",
+		Actor:   actor.id,
+		Object: map[string]any{
+			"type":   "Follow",
+			"actor":  follow.Actor,
+			"object": actor.id,
+		},
+	}
+	return b.deliver(actor, followerInbox, accept)
+}
@@ -0,0 +1,154 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/husio/readium/ssrf"
+)
+
+func newTestBridge(t *testing.T) *Bridge {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "ap.db")
+	b, err := NewBridge("readium.example.com", dbPath, []string{"test"}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewBridge: %v", err)
+	}
+	return b
+}
+
+// remoteActor serves a minimal actor document (with a throwaway RSA
+// keypair) over httptest, so a test can sign inbox deliveries "as" a
+// remote Mastodon/Pleroma account the way a real follower would.
+type remoteActor struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
+func newRemoteActor(t *testing.T) *remoteActor {
+	t.Helper()
+	ssrf.AllowLoopbackForTest(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	ra := &remoteActor{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		w.Header().Set("Content-Type", "application/activity+json")
+		json.NewEncoder(w).Encode(Actor{
+			ID:        ra.id,
+			Type:      "Person",
+			Inbox:     ra.id + "/inbox",
+			PublicKey: PublicKey{ID: ra.id + "#main-key", Owner: ra.id, PublicKeyPem: string(pubPEM)},
+		})
+	})
+	mux.HandleFunc("/users/alice/inbox", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	ra.id = srv.URL + "/users/alice"
+	return ra
+}
+
+// signedRequest builds an HTTP Signature-signed POST of body to target,
+// as if ra were delivering it to an inbox.
+func (ra *remoteActor) signedRequest(t *testing.T, target string, body []byte) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err := signRequest(req, ra.id+"#main-key", ra.key, body); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	return req
+}
+
+func TestServeInboxFollowAndUndo(t *testing.T) {
+	b := newTestBridge(t)
+	alice := newRemoteActor(t)
+
+	followBody, _ := json.Marshal(map[string]any{
+		"type":   "Follow",
+		"actor":  alice.id,
+		"object": b.actors["test"].id,
+	})
+	req := alice.signedRequest(t, "https://readium.example.com/ap/actors/test/inbox", followBody)
+	w := httptest.NewRecorder()
+	b.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Follow: status = %d, want %d; body: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+	followers, err := b.store.Followers("test")
+	if err != nil {
+		t.Fatalf("Followers: %v", err)
+	}
+	if len(followers) != 1 || followers[0].ID != alice.id {
+		t.Fatalf("Followers = %v, want exactly %q", followers, alice.id)
+	}
+
+	undoBody, _ := json.Marshal(map[string]any{
+		"type":  "Undo",
+		"actor": alice.id,
+		"object": map[string]any{
+			"type":   "Follow",
+			"actor":  alice.id,
+			"object": b.actors["test"].id,
+		},
+	})
+	req = alice.signedRequest(t, "https://readium.example.com/ap/actors/test/inbox", undoBody)
+	w = httptest.NewRecorder()
+	b.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Undo: status = %d, want %d; body: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+	followers, err = b.store.Followers("test")
+	if err != nil {
+		t.Fatalf("Followers: %v", err)
+	}
+	if len(followers) != 0 {
+		t.Fatalf("Followers after Undo = %v, want none", followers)
+	}
+}
+
+func TestServeInboxRejectsActorSignerMismatch(t *testing.T) {
+	b := newTestBridge(t)
+	alice := newRemoteActor(t)
+
+	// Signed correctly by alice, but the activity claims to be from
+	// someone else — must be rejected even though the signature verifies.
+	body, _ := json.Marshal(map[string]any{
+		"type":   "Follow",
+		"actor":  "https://evil.example.com/users/mallory",
+		"object": b.actors["test"].id,
+	})
+	req := alice.signedRequest(t, "https://readium.example.com/ap/actors/test/inbox", body)
+	w := httptest.NewRecorder()
+	b.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, http.StatusUnauthorized, w.Body)
+	}
+	followers, err := b.store.Followers("test")
+	if err != nil {
+		t.Fatalf("Followers: %v", err)
+	}
+	if len(followers) != 0 {
+		t.Fatalf("Followers = %v, want none recorded for a rejected request", followers)
+	}
+}
@@ -0,0 +1,124 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signWithHeaders builds a Signature header covering exactly headers,
+// bypassing signRequest's fixed signedHeaders list, so a test can exercise
+// a peer that signs a narrower (but individually valid) header set.
+func signWithHeaders(t *testing.T, req *http.Request, keyID string, key *rsa.PrivateKey, headers []string) {
+	t.Helper()
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig),
+	))
+}
+
+func TestParseSignatureHeaderMissingKeyId(t *testing.T) {
+	if _, err := parseSignatureHeader(`algorithm="rsa-sha256",headers="date",signature="AAAA"`); err == nil {
+		t.Fatal("parseSignatureHeader: want error for header missing keyId, got nil")
+	}
+}
+
+func TestParseSignatureHeaderGarbled(t *testing.T) {
+	if _, err := parseSignatureHeader(`not a signature header at all`); err == nil {
+		t.Fatal("parseSignatureHeader: want error for garbled header, got nil")
+	}
+}
+
+func TestParseSignatureHeaderOK(t *testing.T) {
+	params, err := parseSignatureHeader(`keyId="https://example.com/users/alice#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="AAAA"`)
+	if err != nil {
+		t.Fatalf("parseSignatureHeader: %v", err)
+	}
+	if params.keyID != "https://example.com/users/alice#main-key" {
+		t.Errorf("keyID = %q, want the signer's key URL", params.keyID)
+	}
+	if len(params.headers) != 4 {
+		t.Errorf("headers = %v, want 4 entries", params.headers)
+	}
+}
+
+func TestVerifyRequestMissingSignatureHeader(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://readium.example.com/ap/actors/test/inbox", strings.NewReader("{}"))
+	if _, err := verifyRequest(req, []byte("{}"), http.DefaultClient); err == nil {
+		t.Fatal("verifyRequest: want error for request with no Signature header, got nil")
+	}
+}
+
+func TestVerifyRequestGarbledSignatureHeader(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://readium.example.com/ap/actors/test/inbox", strings.NewReader("{}"))
+	req.Header.Set("Signature", "garbage")
+	if _, err := verifyRequest(req, []byte("{}"), http.DefaultClient); err == nil {
+		t.Fatal("verifyRequest: want error for garbled Signature header, got nil")
+	}
+}
+
+func TestVerifyRequestDigestMismatch(t *testing.T) {
+	body := []byte(`{"type":"Follow"}`)
+	req := httptest.NewRequest(http.MethodPost, "https://readium.example.com/ap/actors/test/inbox", nil)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Signature", `keyId="https://example.com/users/alice#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="AAAA"`)
+	req.Header.Set("Digest", "SHA-256=not-the-real-digest")
+
+	if _, err := verifyRequest(req, body, http.DefaultClient); err == nil {
+		t.Fatal("verifyRequest: want error for a Digest that doesn't match the body, got nil")
+	}
+}
+
+func TestVerifyRequestRejectsMinimalHeaderSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	body := []byte(`{"type":"Follow","actor":"https://example.com/users/alice","object":"https://readium.example.com/ap/actors/test"}`)
+	digest := sha256.Sum256(body)
+
+	req := httptest.NewRequest(http.MethodPost, "https://readium.example.com/ap/actors/test/inbox", nil)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	// A signature that only covers "date" validates the literal Date
+	// header string, but says nothing about the method, path, or body —
+	// so the same signature could be replayed against any forged
+	// Digest/body pair. It must be rejected even though the RSA
+	// signature itself is entirely valid.
+	signWithHeaders(t, req, "https://example.com/users/alice#main-key", key, []string{"date"})
+
+	if _, err := verifyRequest(req, body, http.DefaultClient); err == nil {
+		t.Fatal("verifyRequest: want error for a signature that doesn't cover (request-target) and digest, got nil")
+	}
+}
+
+func TestVerifyRequestRejectsStaleDate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	body := []byte(`{"type":"Follow","actor":"https://example.com/users/alice","object":"https://readium.example.com/ap/actors/test"}`)
+	digest := sha256.Sum256(body)
+
+	req := httptest.NewRequest(http.MethodPost, "https://readium.example.com/ap/actors/test/inbox", nil)
+	req.Header.Set("Date", time.Now().Add(-1*time.Hour).UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	signWithHeaders(t, req, "https://example.com/users/alice#main-key", key, []string{"(request-target)", "host", "date", "digest"})
+
+	if _, err := verifyRequest(req, body, http.DefaultClient); err == nil {
+		t.Fatal("verifyRequest: want error for a Date an hour old, got nil")
+	}
+}
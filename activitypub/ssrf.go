@@ -0,0 +1,45 @@
+package activitypub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/husio/readium/ssrf"
+)
+
+// validateFetchURL rejects anything this package shouldn't fetch on a
+// remote peer's say-so: federation follows URLs a peer fully controls (a
+// Signature's keyId, a follower's actor id), so without this check
+// they'd double as a port-scanning/SSRF oracle against internal
+// infrastructure (cloud metadata endpoints, RFC1918 networks, ...).
+func validateFetchURL(rawURL string) (*url.URL, error) {
+	return ssrf.ValidateURL(rawURL)
+}
+
+// safeClient returns an http.Client equivalent to base, except that every
+// redirect hop is re-validated with validateFetchURL and every dial —
+// including the initial request, not just redirects — is pinned to the
+// address ssrf.Transport validated for it. Without the redirect check, a
+// peer-supplied URL that passes the initial check (fetchPublicKey,
+// resolveInbox, deliver all follow URLs a peer controls) could still
+// reach internal infrastructure by 302-ing somewhere validateFetchURL
+// would have rejected outright. Without the pinned transport, a
+// peer-controlled hostname could pass validateFetchURL's DNS lookup and
+// still resolve to a different, internal address by the time the
+// request actually dials it.
+func safeClient(base *http.Client) *http.Client {
+	client := *base
+	client.Transport = ssrf.Transport()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if _, err := validateFetchURL(req.URL.String()); err != nil {
+			return fmt.Errorf("redirect: %w", err)
+		}
+		return nil
+	}
+	return &client
+}
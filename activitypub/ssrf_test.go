@@ -0,0 +1,35 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/husio/readium/ssrf"
+)
+
+func TestSafeClientRejectsRedirectToBlockedAddress(t *testing.T) {
+	ssrf.AllowLoopbackForTest(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	client := safeClient(srv.Client())
+	_, err := client.Get(srv.URL)
+	if err == nil {
+		t.Fatal("Get: want error when the server redirects to a blocked address, got nil")
+	}
+}
+
+func TestSafeClientRejectsLoopbackByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := safeClient(srv.Client())
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("Get: want error dialing a loopback actor/keyId URL by default, got nil")
+	}
+}
@@ -0,0 +1,189 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// Store persists actor keypairs, follower subscriptions and published
+// activities across restarts. It's the only stateful piece of the bridge;
+// everything else (webfinger, signing, delivery) is derived on the fly.
+type Store struct {
+	db *sql.DB
+}
+
+// OpenStore opens (creating if necessary) the SQLite database at path and
+// ensures its schema exists.
+func OpenStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+CREATE TABLE IF NOT EXISTS actor_keys (
+	name            TEXT PRIMARY KEY,
+	private_key_pem TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS followers (
+	actor_name  TEXT NOT NULL,
+	follower_id TEXT NOT NULL,
+	inbox       TEXT NOT NULL,
+	PRIMARY KEY (actor_name, follower_id)
+);
+CREATE TABLE IF NOT EXISTS outbox_items (
+	actor_name TEXT NOT NULL,
+	id         TEXT PRIMARY KEY,
+	created_at TEXT NOT NULL,
+	activity   TEXT NOT NULL
+);
+`)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// LoadOrCreateKey returns the persisted private key for the named actor,
+// generating and storing a new one the first time it's requested.
+func (s *Store) LoadOrCreateKey(name string) (*rsa.PrivateKey, error) {
+	row := s.db.QueryRow(`SELECT private_key_pem FROM actor_keys WHERE name = ?`, name)
+	var pemStr string
+	switch err := row.Scan(&pemStr); {
+	case err == nil:
+		block, _ := pem.Decode([]byte(pemStr))
+		if block == nil {
+			return nil, fmt.Errorf("decode stored key for %q: no PEM block", name)
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case errors.Is(err, sql.ErrNoRows):
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("generate key for %q: %w", name, err)
+		}
+		block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		if _, err := s.db.Exec(`INSERT INTO actor_keys (name, private_key_pem) VALUES (?, ?)`, name, string(pem.EncodeToMemory(block))); err != nil {
+			return nil, fmt.Errorf("store key for %q: %w", name, err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("load key for %q: %w", name, err)
+	}
+}
+
+// follower is one Mastodon/Pleroma account subscribed to a local actor.
+type follower struct {
+	ID    string
+	Inbox string
+}
+
+// AddFollower records that followerID (with the given inbox) follows
+// actorName. Re-adding an existing follower is a no-op.
+func (s *Store) AddFollower(actorName, followerID, inbox string) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO followers (actor_name, follower_id, inbox) VALUES (?, ?, ?)`, actorName, followerID, inbox)
+	if err != nil {
+		return fmt.Errorf("add follower: %w", err)
+	}
+	return nil
+}
+
+// RemoveFollower drops followerID from actorName's followers, e.g. on an
+// Undo(Follow).
+func (s *Store) RemoveFollower(actorName, followerID string) error {
+	_, err := s.db.Exec(`DELETE FROM followers WHERE actor_name = ? AND follower_id = ?`, actorName, followerID)
+	if err != nil {
+		return fmt.Errorf("remove follower: %w", err)
+	}
+	return nil
+}
+
+// Followers returns everyone currently following actorName.
+func (s *Store) Followers(actorName string) ([]follower, error) {
+	rows, err := s.db.Query(`SELECT follower_id, inbox FROM followers WHERE actor_name = ?`, actorName)
+	if err != nil {
+		return nil, fmt.Errorf("query followers: %w", err)
+	}
+	defer rows.Close()
+
+	var out []follower
+	for rows.Next() {
+		var f follower
+		if err := rows.Scan(&f.ID, &f.Inbox); err != nil {
+			return nil, fmt.Errorf("scan follower: %w", err)
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// FollowerCount returns how many accounts currently follow actorName, for
+// the followers collection's totalItems.
+func (s *Store) FollowerCount(actorName string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT count(*) FROM followers WHERE actor_name = ?`, actorName).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count followers: %w", err)
+	}
+	return n, nil
+}
+
+// AddOutboxItem records activity in actorName's outbox under createdAt,
+// the timestamp RecentOutboxItems orders the outbox by.
+func (s *Store) AddOutboxItem(actorName, id, createdAt string, activity any) error {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("marshal outbox item: %w", err)
+	}
+	_, err = s.db.Exec(`INSERT INTO outbox_items (actor_name, id, created_at, activity) VALUES (?, ?, ?, ?)`, actorName, id, createdAt, string(data))
+	if err != nil {
+		return fmt.Errorf("store outbox item: %w", err)
+	}
+	return nil
+}
+
+// RecentOutboxItems returns up to limit of actorName's most recently
+// published activities, newest first.
+func (s *Store) RecentOutboxItems(actorName string, limit int) ([]json.RawMessage, error) {
+	rows, err := s.db.Query(`SELECT activity FROM outbox_items WHERE actor_name = ? ORDER BY created_at DESC LIMIT ?`, actorName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query outbox items: %w", err)
+	}
+	defer rows.Close()
+
+	var out []json.RawMessage
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("scan outbox item: %w", err)
+		}
+		out = append(out, json.RawMessage(raw))
+	}
+	return out, rows.Err()
+}
+
+// OutboxCount returns how many activities actorName has ever published,
+// for the outbox collection's totalItems.
+func (s *Store) OutboxCount(actorName string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT count(*) FROM outbox_items WHERE actor_name = ?`, actorName).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count outbox items: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+// Package activitypub bridges cached extractions into the Fediverse: one
+// ActivityPub actor per configured upstream source, followable from
+// Mastodon and Pleroma, with a signed Create activity delivered to
+// followers whenever a newly-requested article is cached.
+package activitypub
+
+// context is the JSON-LD @context every ActivityStreams document in this
+// package is served under.
+var context = []string{
+	"https://www.w3.org/ns/activitystreams",
+	"https://w3id.org/security/v1",
+}
+
+// Actor is a minimal ActivityPub actor document: enough for Mastodon and
+// Pleroma to discover an inbox, outbox and public key.
+type Actor struct {
+	Context           []string  `json:"@context"`
+	ID                string    `json:"id"`
+	Type              string    `json:"type"`
+	PreferredUsername string    `json:"preferredUsername"`
+	Name              string    `json:"name,omitempty"`
+	Summary           string    `json:"summary,omitempty"`
+	Inbox             string    `json:"inbox"`
+	Outbox            string    `json:"outbox"`
+	Followers         string    `json:"followers,omitempty"`
+	PublicKey         PublicKey `json:"publicKey"`
+}
+
+// PublicKey is the security vocabulary block Mastodon/Pleroma use to
+// verify an actor's signed requests.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Article is the AS2 object published for each newly extracted page.
+type Article struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Name         string `json:"name,omitempty"`
+	Content      string `json:"content,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Published    string `json:"published,omitempty"`
+	AttributedTo string `json:"attributedTo,omitempty"`
+}
+
+// Activity wraps an object (an Article, or another activity's summary)
+// with the actor that published it, as required for Create, Follow,
+// Accept and Undo.
+type Activity struct {
+	Context any      `json:"@context,omitempty"`
+	ID      string   `json:"id,omitempty"`
+	Type    string   `json:"type"`
+	Actor   string   `json:"actor"`
+	Object  any      `json:"object,omitempty"`
+	To      []string `json:"to,omitempty"`
+}
+
+// orderedCollection is the AS2 container used for outboxes and follower
+// lists.
+type orderedCollection struct {
+	Context      []string `json:"@context"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	TotalItems   int      `json:"totalItems"`
+	OrderedItems []any    `json:"orderedItems"`
+}
@@ -0,0 +1,65 @@
+package activitypub
+
+// webfinger.go implements the minimal discovery endpoints Mastodon and
+// Pleroma use to resolve an "@account@domain" handle to its actor URL.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type webfingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+type webfingerResource struct {
+	Subject string          `json:"subject"`
+	Links   []webfingerLink `json:"links"`
+}
+
+func (b *Bridge) serveWebfinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	name, ok := b.actorNameFromAcct(resource)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jrd+json")
+	json.NewEncoder(w).Encode(webfingerResource{
+		Subject: resource,
+		Links: []webfingerLink{
+			{Rel: "self", Type: "application/activity+json", Href: b.actors[name].id},
+		},
+	})
+}
+
+// actorNameFromAcct extracts the local part of an "acct:name@domain"
+// resource, if domain matches this bridge and name is a known actor.
+func (b *Bridge) actorNameFromAcct(resource string) (string, bool) {
+	rest, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		return "", false
+	}
+	name, domain, ok := strings.Cut(rest, "@")
+	if !ok || domain != b.domain {
+		return "", false
+	}
+	if _, ok := b.actors[name]; !ok {
+		return "", false
+	}
+	return name, true
+}
+
+func (b *Bridge) serveHostMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xrd+xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">
+  <Link rel="lrdd" type="application/jrd+json" template="https://%s/.well-known/webfinger?resource={uri}"/>
+</XRD>
+`, b.domain)
+}
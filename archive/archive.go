@@ -0,0 +1,233 @@
+// Package archive persists every successfully extracted article to a
+// local SQLite database with an FTS5 full-text index, turning readium
+// from a stateless proxy into a personal read-it-later archive: pages
+// remain searchable and readable after a restart, or after the original
+// later gets paywalled or deleted upstream.
+package archive
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/husio/readium/readability"
+	"github.com/husio/readium/ssrf"
+)
+
+// maxImageBytes caps how much of a single referenced image is saved to
+// disk, so one oversized asset can't blow up the archive.
+const maxImageBytes = 5 << 20 // 5MiB
+
+// Store is a persistent archive of extracted articles, backed by SQLite
+// with an FTS5 index over each article's plain text. Images referenced by
+// an archived article are fetched once and kept on disk under imageDir,
+// rather than blobbed into the database, so the db file stays small
+// enough to back up or sync on its own.
+type Store struct {
+	db       *sql.DB
+	imageDir string
+	client   *http.Client
+}
+
+// OpenStore opens (creating if necessary) the SQLite database at dbPath
+// and ensures its schema exists. Fetched images are saved under imageDir,
+// which is created if it doesn't exist; client is used to fetch them.
+func OpenStore(dbPath, imageDir string, client *http.Client) (*Store, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	if err := os.MkdirAll(imageDir, 0o755); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create image dir: %w", err)
+	}
+
+	s := &Store{db: db, imageDir: imageDir, client: client}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+CREATE TABLE IF NOT EXISTS articles (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	url        TEXT NOT NULL,
+	fetched_at TEXT NOT NULL,
+	title      TEXT NOT NULL,
+	byline     TEXT NOT NULL,
+	published  TEXT NOT NULL,
+	html       TEXT NOT NULL,
+	plain_text TEXT NOT NULL,
+	images     TEXT NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS articles_fts USING fts5(
+	title, plain_text, content='articles', content_rowid='id'
+);
+`)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// Article is an archived extraction, as returned by Search.
+type Article struct {
+	ID        int64
+	URL       string
+	FetchedAt time.Time
+	Title     string
+	Byline    string
+	Published time.Time
+	Images    []string // local file paths, in document order
+	Snippet   string   // only set by Search, a highlighted match excerpt
+}
+
+// Save records art, fetched from url at fetchedAt, as a new archive
+// entry. Every image art references is fetched through the Store's
+// client and saved under imageDir; an image that fails to fetch or
+// exceeds maxImageBytes is simply omitted from the saved list.
+func (s *Store) Save(url string, fetchedAt time.Time, art *readability.Article) (int64, error) {
+	images := s.saveImages(art.Images)
+	imagesJSON, err := json.Marshal(images)
+	if err != nil {
+		return 0, fmt.Errorf("marshal images: %w", err)
+	}
+
+	var published string
+	if !art.Published.IsZero() {
+		published = art.Published.Format(time.RFC3339)
+	}
+
+	res, err := s.db.Exec(
+		`INSERT INTO articles (url, fetched_at, title, byline, published, html, plain_text, images)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		url, fetchedAt.Format(time.RFC3339), art.Title, art.Byline, published, art.HTML, art.PlainText, string(imagesJSON),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert article: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert article: %w", err)
+	}
+
+	if _, err := s.db.Exec(
+		`INSERT INTO articles_fts (rowid, title, plain_text) VALUES (?, ?, ?)`,
+		id, art.Title, art.PlainText,
+	); err != nil {
+		return 0, fmt.Errorf("index article: %w", err)
+	}
+	return id, nil
+}
+
+// saveImages fetches each of urls through s.client and writes it under
+// s.imageDir, returning the local paths of the ones that succeeded.
+func (s *Store) saveImages(urls []string) []string {
+	var saved []string
+	for _, u := range urls {
+		path, err := s.saveImage(u)
+		if err != nil {
+			continue
+		}
+		saved = append(saved, path)
+	}
+	return saved
+}
+
+// saveImage fetches url, which comes from an already-extracted article's
+// Images and so is attacker-controlled (it's whatever the upstream page
+// put there), and writes it under s.imageDir.
+func (s *Store) saveImage(url string) (string, error) {
+	u, err := ssrf.ValidateURL(url)
+	if err != nil {
+		return "", fmt.Errorf("image url: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("build image request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch image: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	if len(data) > maxImageBytes {
+		return "", fmt.Errorf("image exceeds %d byte limit", maxImageBytes)
+	}
+
+	sum := sha1.Sum([]byte(url))
+	name := hex.EncodeToString(sum[:]) + imageExt(url)
+	path := filepath.Join(s.imageDir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("write image: %w", err)
+	}
+	return path, nil
+}
+
+// imageExt guesses a file extension from whatever follows the last "."
+// after url's last "/" (a query string included), defaulting to ".img"
+// when there is none or it's longer than 5 bytes, so the saved file
+// still has one.
+func imageExt(url string) string {
+	if i := strings.LastIndexByte(url, '.'); i >= 0 && i > strings.LastIndexByte(url, '/') {
+		if ext := url[i:]; len(ext) <= 5 {
+			return ext
+		}
+	}
+	return ".img"
+}
+
+// Search returns up to limit archived articles whose title or plain text
+// matches query (FTS5 query syntax), newest first, each with a snippet
+// highlighting the match.
+func (s *Store) Search(query string, limit int) ([]Article, error) {
+	rows, err := s.db.Query(`
+SELECT a.id, a.url, a.fetched_at, a.title, a.byline, a.published, a.images,
+       snippet(articles_fts, 1, '<mark>', '</mark>', '…', 16)
+FROM articles_fts
+JOIN articles a ON a.id = articles_fts.rowid
+WHERE articles_fts MATCH ?
+ORDER BY a.id DESC
+LIMIT ?`, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Article
+	for rows.Next() {
+		var a Article
+		var fetchedAt, published, imagesJSON string
+		if err := rows.Scan(&a.ID, &a.URL, &fetchedAt, &a.Title, &a.Byline, &published, &imagesJSON, &a.Snippet); err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		a.FetchedAt, _ = time.Parse(time.RFC3339, fetchedAt)
+		if published != "" {
+			a.Published, _ = time.Parse(time.RFC3339, published)
+		}
+		json.Unmarshal([]byte(imagesJSON), &a.Images)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/husio/readium/readability"
+	"github.com/husio/readium/ssrf"
+)
+
+func TestSaveAndSearch(t *testing.T) {
+	ssrf.AllowLoopbackForTest(t)
+	img := []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	store, err := OpenStore(filepath.Join(dir, "archive.db"), filepath.Join(dir, "images"), srv.Client())
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+
+	art := &readability.Article{
+		Title:     "A Great Post",
+		Byline:    "Jane Doe",
+		HTML:      `<p>Hello, readers of the archive.</p>`,
+		PlainText: "Hello, readers of the archive.",
+		Images:    []string{srv.URL + "/cover.png"},
+	}
+	if _, err := store.Save(srv.URL+"/posts/great", time.Now(), art); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	results, err := store.Search("archive", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search returned %d results, want 1", len(results))
+	}
+	if results[0].Title != "A Great Post" {
+		t.Errorf("Title = %q, want %q", results[0].Title, "A Great Post")
+	}
+	if len(results[0].Images) != 1 {
+		t.Errorf("Images = %v, want 1 saved image", results[0].Images)
+	}
+}
+
+func TestSaveSkipsImagesAtNonPublicAddresses(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(filepath.Join(dir, "archive.db"), filepath.Join(dir, "images"), http.DefaultClient)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+
+	art := &readability.Article{
+		Title:     "A Great Post",
+		HTML:      `<p>Hello</p>`,
+		PlainText: "Hello",
+		Images:    []string{"http://169.254.169.254/latest/meta-data/"},
+	}
+	id, err := store.Save("https://example.com/posts/great", time.Now(), art)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	results, err := store.Search("Hello", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != id {
+		t.Fatalf("Search = %v, want the saved article", results)
+	}
+	if len(results[0].Images) != 0 {
+		t.Errorf("Images = %v, want the link-local image left unfetched", results[0].Images)
+	}
+}
+
+func TestSearchNoMatch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenStore(filepath.Join(dir, "archive.db"), filepath.Join(dir, "images"), http.DefaultClient)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+
+	results, err := store.Search("nonexistent", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Search returned %d results, want 0", len(results))
+	}
+}
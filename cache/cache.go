@@ -0,0 +1,177 @@
+// Package cache implements a size- and TTL-bounded LRU cache with
+// singleflight-style deduplication of concurrent fetches for the same key.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is a single cached response.
+type Entry struct {
+	Code int
+	Body []byte
+
+	// Hits counts how many times this entry has been served from the
+	// cache. Read and written atomically.
+	Hits int64
+
+	expiresAt time.Time
+}
+
+type entryRecord struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// Stats is a point-in-time snapshot of cache statistics.
+type Stats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int
+	Bytes     int64
+}
+
+type pendingFetch struct {
+	wg    sync.WaitGroup
+	entry *Entry
+	err   error
+}
+
+// Cache is an LRU cache bounded by entry count and total byte size, with a
+// per-entry TTL. Its mutex only ever guards the cache's own bookkeeping
+// (the LRU list, index and byte count) — the upstream fetch passed to
+// Fetch runs outside the lock, so one slow fetch doesn't block unrelated
+// requests.
+type Cache struct {
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	index map[string]*list.Element
+	bytes int64
+
+	pending map[string]*pendingFetch
+
+	hits, misses, evictions int64
+}
+
+// New creates a Cache. maxEntries <= 0 means no entry-count limit;
+// maxBytes <= 0 means no byte-size limit; ttl <= 0 means entries never
+// expire on their own.
+func New(maxEntries int, maxBytes int64, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		ll:         list.New(),
+		index:      make(map[string]*list.Element),
+		pending:    make(map[string]*pendingFetch),
+	}
+}
+
+// Fetch returns the cached entry for key if present and not expired,
+// otherwise it calls fetch to populate one. Concurrent calls for the same
+// key while a fetch is in flight wait for and share its result rather than
+// issuing their own upstream request.
+func (c *Cache) Fetch(key string, fetch func() (*Entry, error)) (*Entry, error) {
+	c.mu.Lock()
+	if e, ok := c.getLocked(key); ok {
+		c.hits++
+		c.mu.Unlock()
+		atomic.AddInt64(&e.Hits, 1)
+		return e, nil
+	}
+	if p, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		p.wg.Wait()
+		return p.entry, p.err
+	}
+	p := &pendingFetch{}
+	p.wg.Add(1)
+	c.pending[key] = p
+	c.mu.Unlock()
+
+	entry, err := fetch()
+	p.entry, p.err = entry, err
+	p.wg.Done()
+
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.misses++
+	if err == nil {
+		c.setLocked(key, entry)
+	}
+	c.mu.Unlock()
+
+	return entry, err
+}
+
+func (c *Cache) getLocked(key string) (*Entry, bool) {
+	el, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	rec := el.Value.(*entryRecord)
+	if c.ttl > 0 && time.Now().After(rec.entry.expiresAt) {
+		c.removeLocked(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return rec.entry, true
+}
+
+func (c *Cache) setLocked(key string, entry *Entry) {
+	size := int64(len(entry.Body))
+	if c.ttl > 0 {
+		entry.expiresAt = time.Now().Add(c.ttl)
+	}
+	if el, ok := c.index[key]; ok {
+		c.bytes -= el.Value.(*entryRecord).size
+		el.Value = &entryRecord{key: key, entry: entry, size: size}
+		c.bytes += size
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&entryRecord{key: key, entry: entry, size: size})
+		c.index[key] = el
+		c.bytes += size
+	}
+	c.evictLocked()
+}
+
+func (c *Cache) evictLocked() {
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		back := c.ll.Back()
+		if back == nil {
+			break
+		}
+		c.removeLocked(back)
+		c.evictions++
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	rec := el.Value.(*entryRecord)
+	delete(c.index, rec.key)
+	c.ll.Remove(el)
+	c.bytes -= rec.size
+}
+
+// Stats returns a snapshot of the cache's current statistics.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   c.ll.Len(),
+		Bytes:     c.bytes,
+	}
+}
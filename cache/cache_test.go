@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchCachesAndCountsHits(t *testing.T) {
+	c := New(10, 0, 0)
+
+	var calls int
+	fetch := func() (*Entry, error) {
+		calls++
+		return &Entry{Code: 200, Body: []byte("hello")}, nil
+	}
+
+	if _, err := c.Fetch("a", fetch); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if _, err := c.Fetch("a", fetch); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("fetch called %d times, want 1", calls)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+}
+
+func TestFetchDedupesConcurrentCalls(t *testing.T) {
+	c := New(10, 0, 0)
+
+	var calls int32
+	start := make(chan struct{})
+	fetch := func() (*Entry, error) {
+		calls++
+		<-start
+		return &Entry{Code: 200, Body: []byte("hello")}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Fetch("a", fetch); err != nil {
+				t.Errorf("Fetch: %v", err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fetch called %d times concurrently, want 1", calls)
+	}
+}
+
+func TestEvictsOldestWhenOverEntryLimit(t *testing.T) {
+	c := New(2, 0, 0)
+
+	mk := func(key string) func() (*Entry, error) {
+		return func() (*Entry, error) { return &Entry{Code: 200, Body: []byte(key)}, nil }
+	}
+	c.Fetch("a", mk("a"))
+	c.Fetch("b", mk("b"))
+	c.Fetch("c", mk("c"))
+
+	stats := c.Stats()
+	if stats.Entries != 2 {
+		t.Errorf("entries = %d, want 2", stats.Entries)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("evictions = %d, want 1", stats.Evictions)
+	}
+
+	var calls int
+	c.Fetch("a", func() (*Entry, error) {
+		calls++
+		return &Entry{Code: 200, Body: []byte("a")}, nil
+	})
+	if calls != 1 {
+		t.Errorf("\"a\" should have been evicted and re-fetched")
+	}
+}
+
+func TestEntryExpiresAfterTTL(t *testing.T) {
+	c := New(10, 0, 20*time.Millisecond)
+
+	var calls int
+	fetch := func() (*Entry, error) {
+		calls++
+		return &Entry{Code: 200, Body: []byte("hello")}, nil
+	}
+
+	c.Fetch("a", fetch)
+	time.Sleep(30 * time.Millisecond)
+	c.Fetch("a", fetch)
+
+	if calls != 2 {
+		t.Errorf("fetch called %d times, want 2 (expired entry should be refetched)", calls)
+	}
+}
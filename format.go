@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/husio/readium/format"
+	"github.com/husio/readium/readability"
+)
+
+// outputFormat is the representation a client asked for, selected via a
+// recognized path suffix (e.g. "/article.epub") or an Accept header.
+type outputFormat int
+
+const (
+	formatHTML outputFormat = iota
+	formatEPUB
+	formatMarkdown
+	formatJSON
+)
+
+// suffixFormats maps a path suffix to the format it selects.
+var suffixFormats = map[string]outputFormat{
+	".epub": formatEPUB,
+	".md":   formatMarkdown,
+	".json": formatJSON,
+}
+
+// acceptFormats maps an Accept media type to the format it selects. Checked
+// in order so the result doesn't depend on map iteration.
+var acceptFormats = []struct {
+	mediaType string
+	format    outputFormat
+}{
+	{"application/epub+zip", formatEPUB},
+	{"text/markdown", formatMarkdown},
+	{"application/json", formatJSON},
+}
+
+// negotiateFormat works out which representation r is asking for. It
+// returns the request path with any recognized format suffix stripped, so
+// the caller can resolve the upstream URL as if the suffix wasn't there.
+func negotiateFormat(r *http.Request) (outputFormat, string) {
+	path := r.URL.Path
+	for suffix, f := range suffixFormats {
+		if rest, ok := strings.CutSuffix(path, suffix); ok {
+			return f, rest
+		}
+	}
+	accept := r.Header.Get("Accept")
+	for _, af := range acceptFormats {
+		if acceptsMediaType(accept, af.mediaType) {
+			return af.format, path
+		}
+	}
+	return formatHTML, path
+}
+
+// acceptsMediaType reports whether accept (an Accept header value) lists
+// mediaType among its comma-separated entries, ignoring any ";q=..." or
+// other parameters.
+func acceptsMediaType(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if semi := strings.IndexByte(part, ';'); semi >= 0 {
+			part = part[:semi]
+		}
+		if part == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// contentType is the header value to serve f under.
+func (f outputFormat) contentType() string {
+	switch f {
+	case formatEPUB:
+		return "application/epub+zip"
+	case formatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case formatJSON:
+		return "application/json"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// cacheSuffix disambiguates cache keys so the same upstream URL requested
+// as, say, HTML and EPUB doesn't collide on a single cache entry.
+func (f outputFormat) cacheSuffix() string {
+	switch f {
+	case formatEPUB:
+		return "#epub"
+	case formatMarkdown:
+		return "#md"
+	case formatJSON:
+		return "#json"
+	default:
+		return ""
+	}
+}
+
+// render serializes art into f. It only handles the non-HTML formats;
+// formatHTML keeps its existing inline rendering in ServeHTTP.
+func (rd *readium) render(f outputFormat, art *readability.Article) ([]byte, error) {
+	switch f {
+	case formatEPUB:
+		var buf bytes.Buffer
+		if err := format.ToEPUB(&buf, art, &rd.client); err != nil {
+			return nil, fmt.Errorf("render epub: %w", err)
+		}
+		return buf.Bytes(), nil
+	case formatMarkdown:
+		md, err := format.ToMarkdown(art)
+		if err != nil {
+			return nil, fmt.Errorf("render markdown: %w", err)
+		}
+		return []byte(md), nil
+	case formatJSON:
+		j, err := format.ToJSON(art)
+		if err != nil {
+			return nil, fmt.Errorf("render json: %w", err)
+		}
+		return j, nil
+	default:
+		return nil, fmt.Errorf("render: unsupported format %d", f)
+	}
+}
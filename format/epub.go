@@ -0,0 +1,316 @@
+package format
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/husio/readium/readability"
+	"github.com/husio/readium/ssrf"
+)
+
+// maxInlineImageBytes caps how much of a single referenced image is read
+// before it's dropped from the package, so one oversized asset can't make
+// the EPUB unusably large.
+const maxInlineImageBytes = 5 << 20 // 5MiB
+
+// epubImage is an image referenced by the article that was fetched and
+// will be packaged alongside it.
+type epubImage struct {
+	id        string
+	name      string // path within OEBPS/, e.g. "images/img1.jpg"
+	mediaType string
+	data      []byte
+}
+
+// ToEPUB writes art to w as a self-contained EPUB 3 package: the required
+// mimetype/container/OPF/NCX plumbing plus the article as a single XHTML
+// document. Every <img> it references is fetched through client and
+// inlined, so the result is readable offline on e-readers.
+func ToEPUB(w io.Writer, art *readability.Article, client *http.Client) error {
+	images, body := inlineImages(art.HTML, client)
+	id := bookID(art)
+
+	zw := zip.NewWriter(w)
+
+	// mimetype must be the zip's first entry and stored uncompressed for
+	// the package to be recognized as an EPUB.
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("write mimetype entry: %w", err)
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return fmt.Errorf("write mimetype entry: %w", err)
+	}
+
+	textFiles := []struct {
+		name, data string
+	}{
+		{"META-INF/container.xml", containerXML},
+		{"OEBPS/content.opf", contentOPF(art, id, images)},
+		{"OEBPS/toc.ncx", tocNCX(art, id)},
+		{"OEBPS/content.xhtml", contentXHTML(art, body)},
+	}
+	for _, f := range textFiles {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", f.name, err)
+		}
+		if _, err := io.WriteString(fw, f.data); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	for _, img := range images {
+		fw, err := zw.Create("OEBPS/" + img.name)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", img.name, err)
+		}
+		if _, err := fw.Write(img.data); err != nil {
+			return fmt.Errorf("write %s: %w", img.name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// inlineImages parses articleHTML, fetches every <img src> it finds
+// through client and rewrites it to point at the packaged copy, and
+// returns the fetched images alongside the rewritten HTML. Images that
+// fail to fetch, exceed maxInlineImageBytes, or aren't a recognized image
+// type are left pointing at their original (remote) URL.
+func inlineImages(articleHTML string, client *http.Client) ([]epubImage, string) {
+	nodes, err := html.ParseFragment(strings.NewReader(articleHTML), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, articleHTML
+	}
+
+	var images []epubImage
+	resolved := map[string]int{} // src -> index into images, -1 if fetching it failed
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "img" {
+			for i, a := range n.Attr {
+				if a.Key != "src" {
+					continue
+				}
+				idx, ok := resolved[a.Val]
+				if !ok {
+					img, err := fetchImage(client, a.Val, len(images)+1)
+					if err != nil {
+						idx = -1
+					} else {
+						idx = len(images)
+						images = append(images, *img)
+					}
+					resolved[a.Val] = idx
+				}
+				if idx >= 0 {
+					n.Attr[i].Val = images[idx].name
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	var buf strings.Builder
+	for _, n := range nodes {
+		html.Render(&buf, n)
+	}
+	return images, buf.String()
+}
+
+// fetchImage downloads src, which must be an absolute http(s) URL, and
+// returns it as a packaged image named from seq. src comes from an
+// already-extracted article's HTML, i.e. from whatever upstream site
+// readium fetched, so it's validated the same as any other untrusted
+// URL before being dialed.
+func fetchImage(client *http.Client, src string, seq int) (*epubImage, error) {
+	u, err := ssrf.ValidateURL(src)
+	if err != nil {
+		return nil, fmt.Errorf("image url: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build image request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch image: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxInlineImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if len(data) > maxInlineImageBytes {
+		return nil, fmt.Errorf("image exceeds %d byte limit", maxInlineImageBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	mediaType, ext := imageMediaType(contentType)
+	if mediaType == "" {
+		return nil, fmt.Errorf("unsupported image content type %q", contentType)
+	}
+
+	return &epubImage{
+		id:        fmt.Sprintf("img%d", seq),
+		name:      fmt.Sprintf("images/img%d%s", seq, ext),
+		mediaType: mediaType,
+		data:      data,
+	}, nil
+}
+
+// imageMediaType maps a Content-Type to the EPUB manifest media-type and
+// file extension to store the image under, or ("", "") if contentType
+// isn't a format EPUB readers are expected to support.
+func imageMediaType(contentType string) (mediaType, ext string) {
+	switch {
+	case strings.HasPrefix(contentType, "image/jpeg"):
+		return "image/jpeg", ".jpg"
+	case strings.HasPrefix(contentType, "image/png"):
+		return "image/png", ".png"
+	case strings.HasPrefix(contentType, "image/gif"):
+		return "image/gif", ".gif"
+	case strings.HasPrefix(contentType, "image/webp"):
+		return "image/webp", ".webp"
+	case strings.HasPrefix(contentType, "image/svg+xml"):
+		return "image/svg+xml", ".svg"
+	default:
+		return "", ""
+	}
+}
+
+// bookID derives a stable dc:identifier for art from its canonical URL (or
+// title, if that's empty), so repeated conversions of the same article
+// produce the same identifier.
+func bookID(art *readability.Article) string {
+	seed := art.CanonicalURL
+	if seed == "" {
+		seed = art.Title
+	}
+	sum := sha1.Sum([]byte(seed))
+	return "urn:sha1:" + hex.EncodeToString(sum[:])
+}
+
+// xmlEscape escapes s for safe inclusion as XML character data, so an
+// article title or byline containing "&", "<", ">" etc. can't break the
+// OPF/NCX/XHTML documents it's interpolated into.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func contentOPF(art *readability.Article, id string, images []epubImage) string {
+	var manifest strings.Builder
+	manifest.WriteString(`    <item id="content" href="content.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+	manifest.WriteString(`    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+	for _, img := range images {
+		fmt.Fprintf(&manifest, "    <item id=%q href=%q media-type=%q/>\n", img.id, img.name, img.mediaType)
+	}
+
+	author := art.Byline
+	if author == "" {
+		author = "Unknown"
+	}
+	author = xmlEscape(author)
+	title := xmlEscape(art.Title)
+	published := art.Published
+	if published.IsZero() {
+		published = time.Now().UTC()
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">%s</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:creator>%s</dc:creator>
+    <dc:language>en</dc:language>
+    <dc:date>%s</dc:date>
+    <meta property="dcterms:modified">%s</meta>
+  </metadata>
+  <manifest>
+%s  </manifest>
+  <spine toc="ncx">
+    <itemref idref="content"/>
+  </spine>
+</package>
+`, id, title, author, published.Format("2006-01-02"), time.Now().UTC().Format("2006-01-02T15:04:05Z"), manifest.String())
+}
+
+func tocNCX(art *readability.Article, id string) string {
+	title := art.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	title = xmlEscape(title)
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="%s"/>
+  </head>
+  <docTitle>
+    <text>%s</text>
+  </docTitle>
+  <navMap>
+    <navPoint id="navpoint-1" playOrder="1">
+      <navLabel><text>%s</text></navLabel>
+      <content src="content.xhtml"/>
+    </navPoint>
+  </navMap>
+</ncx>
+`, id, title, title)
+}
+
+func contentXHTML(art *readability.Article, body string) string {
+	var header strings.Builder
+	if art.Title != "" {
+		fmt.Fprintf(&header, "<h1>%s</h1>\n", xmlEscape(art.Title))
+	}
+	if art.Byline != "" {
+		fmt.Fprintf(&header, "<p><em>%s</em></p>\n", xmlEscape(art.Byline))
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>%s</title></head>
+<body>
+%s%s
+</body>
+</html>
+`, xmlEscape(art.Title), header.String(), body)
+}
@@ -0,0 +1,107 @@
+package format
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/husio/readium/readability"
+	"github.com/husio/readium/ssrf"
+)
+
+func TestToEPUBInlinesImages(t *testing.T) {
+	ssrf.AllowLoopbackForTest(t)
+	img := []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	art := &readability.Article{
+		Title:        "A Great Post",
+		Byline:       "Jane Doe",
+		CanonicalURL: srv.URL + "/posts/great",
+		HTML:         `<p>Hello</p><img src="` + srv.URL + `/cover.png">`,
+	}
+
+	var buf bytes.Buffer
+	if err := ToEPUB(&buf, art, srv.Client()); err != nil {
+		t.Fatalf("ToEPUB: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open epub as zip: %v", err)
+	}
+	if len(zr.File) == 0 || zr.File[0].Name != "mimetype" {
+		t.Fatalf("first zip entry = %v, want mimetype", zr.File)
+	}
+
+	var content, image []byte
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data := make([]byte, f.UncompressedSize64)
+		if _, err := rc.Read(data); err != nil && err.Error() != "EOF" {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		rc.Close()
+		switch f.Name {
+		case "OEBPS/content.xhtml":
+			content = data
+		case "OEBPS/images/img1.png":
+			image = data
+		}
+	}
+
+	if !strings.Contains(string(content), "images/img1.png") {
+		t.Errorf("content.xhtml does not reference the inlined image: %s", content)
+	}
+	if !bytes.Equal(image, img) {
+		t.Errorf("packaged image = %v, want %v", image, img)
+	}
+}
+
+func TestToEPUBSkipsImagesAtNonPublicAddresses(t *testing.T) {
+	art := &readability.Article{
+		Title: "A Great Post",
+		HTML:  `<p>Hello</p><img src="http://169.254.169.254/latest/meta-data/">`,
+	}
+
+	var buf bytes.Buffer
+	if err := ToEPUB(&buf, art, http.DefaultClient); err != nil {
+		t.Fatalf("ToEPUB: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open epub as zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "OEBPS/images/") {
+			t.Errorf("packaged %s, want the link-local image left unfetched", f.Name)
+		}
+	}
+}
+
+func TestContentOPFEscapesTitle(t *testing.T) {
+	art := &readability.Article{Title: "Cats & Dogs <3", Byline: "Q&A"}
+	opf := contentOPF(art, "urn:test", nil)
+
+	var doc struct {
+		XMLName xml.Name `xml:"package"`
+	}
+	if err := xml.Unmarshal([]byte(opf), &doc); err != nil {
+		t.Fatalf("content.opf is not valid XML: %v\n%s", err, opf)
+	}
+	if strings.Contains(opf, "Cats & Dogs") {
+		t.Errorf("title should have been escaped: %s", opf)
+	}
+}
@@ -0,0 +1,35 @@
+package format
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/husio/readium/readability"
+)
+
+// Envelope is the JSON representation of an extracted article.
+type Envelope struct {
+	Title     string   `json:"title"`
+	Byline    string   `json:"byline,omitempty"`
+	Published string   `json:"published,omitempty"`
+	Canonical string   `json:"canonical_url,omitempty"`
+	HTML      string   `json:"html"`
+	Text      string   `json:"text"`
+	Images    []string `json:"images,omitempty"`
+}
+
+// ToJSON renders art as a structured JSON envelope.
+func ToJSON(art *readability.Article) ([]byte, error) {
+	env := Envelope{
+		Title:     art.Title,
+		Byline:    art.Byline,
+		Canonical: art.CanonicalURL,
+		HTML:      art.HTML,
+		Text:      art.PlainText,
+		Images:    art.Images,
+	}
+	if !art.Published.IsZero() {
+		env.Published = art.Published.Format(time.RFC3339)
+	}
+	return json.MarshalIndent(env, "", "  ")
+}
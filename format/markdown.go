@@ -0,0 +1,109 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/husio/readium/readability"
+)
+
+// ToMarkdown renders art's title, byline and HTML body as CommonMark.
+func ToMarkdown(art *readability.Article) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(art.HTML), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("parse article html: %w", err)
+	}
+
+	var buf strings.Builder
+	if art.Title != "" {
+		fmt.Fprintf(&buf, "# %s\n\n", art.Title)
+	}
+	if art.Byline != "" {
+		fmt.Fprintf(&buf, "_%s_\n\n", art.Byline)
+	}
+	for _, n := range nodes {
+		writeMarkdown(&buf, n)
+	}
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
+
+func writeMarkdown(buf *strings.Builder, n *html.Node) {
+	if n.Type == html.TextNode {
+		buf.WriteString(n.Data)
+		return
+	}
+	if n.Type != html.ElementNode {
+		writeChildren(buf, n)
+		return
+	}
+
+	switch n.Data {
+	case "h1":
+		buf.WriteString("\n# ")
+		writeChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "h2":
+		buf.WriteString("\n## ")
+		writeChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "h3":
+		buf.WriteString("\n### ")
+		writeChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "p", "div", "section", "figure", "figcaption":
+		writeChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "strong", "b":
+		buf.WriteString("**")
+		writeChildren(buf, n)
+		buf.WriteString("**")
+	case "em", "i":
+		buf.WriteString("_")
+		writeChildren(buf, n)
+		buf.WriteString("_")
+	case "a":
+		buf.WriteString("[")
+		writeChildren(buf, n)
+		fmt.Fprintf(buf, "](%s)", attr(n, "href"))
+	case "img":
+		fmt.Fprintf(buf, "![%s](%s)", attr(n, "alt"), attr(n, "src"))
+	case "blockquote":
+		buf.WriteString("> ")
+		writeChildren(buf, n)
+		buf.WriteString("\n\n")
+	case "pre":
+		buf.WriteString("```\n")
+		writeChildren(buf, n)
+		buf.WriteString("\n```\n\n")
+	case "code":
+		buf.WriteString("`")
+		writeChildren(buf, n)
+		buf.WriteString("`")
+	case "br":
+		buf.WriteString("  \n")
+	default:
+		writeChildren(buf, n)
+	}
+}
+
+func writeChildren(buf *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeMarkdown(buf, c)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
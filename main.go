@@ -2,19 +2,77 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"sync"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"golang.org/x/net/html"
+	"github.com/husio/readium/activitypub"
+	"github.com/husio/readium/archive"
+	"github.com/husio/readium/cache"
+	"github.com/husio/readium/readability"
+	"github.com/husio/readium/ssrf"
 )
 
 func main() {
 	port := env("PORT", "5000")
-	http.Handle("/", &readium{})
+
+	sources := defaultSources()
+	if path := os.Getenv("READIUM_SOURCES"); path != "" {
+		loaded, err := loadSources(path)
+		if err != nil {
+			log.Fatalf("load sources: %v", err)
+		}
+		sources = loaded
+	}
+
+	maxEntries, err := strconv.Atoi(env("READIUM_CACHE_ENTRIES", "200"))
+	if err != nil {
+		log.Fatalf("parse READIUM_CACHE_ENTRIES: %v", err)
+	}
+	maxBytes, err := strconv.ParseInt(env("READIUM_CACHE_BYTES", "67108864"), 10, 64)
+	if err != nil {
+		log.Fatalf("parse READIUM_CACHE_BYTES: %v", err)
+	}
+	ttl, err := time.ParseDuration(env("READIUM_CACHE_TTL", "1h"))
+	if err != nil {
+		log.Fatalf("parse READIUM_CACHE_TTL: %v", err)
+	}
+
+	rd := newReadium(sources, cache.New(maxEntries, maxBytes, ttl))
+
+	if domain := os.Getenv("READIUM_AP_DOMAIN"); domain != "" {
+		names := make([]string, len(sources))
+		for i, src := range sources {
+			names[i] = src.Name
+		}
+		bridge, err := activitypub.NewBridge(domain, env("READIUM_AP_DB", "readium-ap.db"), names, &rd.client)
+		if err != nil {
+			log.Fatalf("activitypub bridge: %v", err)
+		}
+		rd.bridge = bridge
+		http.Handle("/.well-known/", bridge.Handler())
+		http.Handle("/ap/", bridge.Handler())
+	}
+
+	if dbPath := os.Getenv("READIUM_ARCHIVE_DB"); dbPath != "" {
+		imageDir := env("READIUM_ARCHIVE_IMAGES", dbPath+"-images")
+		store, err := archive.OpenStore(dbPath, imageDir, &rd.client)
+		if err != nil {
+			log.Fatalf("archive store: %v", err)
+		}
+		rd.archive = store
+	}
+
+	http.Handle("/", rd)
 	http.ListenAndServe(":"+port, nil)
 }
 
@@ -26,15 +84,26 @@ func env(name, fallback string) string {
 }
 
 type readium struct {
-	client http.Client
-	mu     sync.Mutex
-	cache  map[string]*page
+	client  http.Client
+	sources []SourceConfig
+	cache   *cache.Cache
+	bridge  *activitypub.Bridge
+	archive *archive.Store
 }
 
-type page struct {
-	hits    int
-	code    int
-	content string
+func newReadium(sources []SourceConfig, c *cache.Cache) *readium {
+	rd := &readium{sources: sources, cache: c}
+	rd.client.Transport = ssrf.Transport()
+	rd.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if _, err := ssrf.ValidateURL(req.URL.String()); err != nil {
+			return fmt.Errorf("redirect: %w", err)
+		}
+		return nil
+	}
+	return rd
 }
 
 func (rd *readium) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -42,19 +111,60 @@ func (rd *readium) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		io.WriteString(w, `<!doctype html>Hello.`)
 		return
 	}
+	if r.URL.Path == "/debug/cache" {
+		rd.serveDebugCache(w, r)
+		return
+	}
+	if r.URL.Path == "/search" {
+		rd.serveSearch(w, r)
+		return
+	}
+
+	outFormat, path := negotiateFormat(r)
+	if path != r.URL.Path {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r = r2
+	}
 
-	rd.mu.Lock()
-	defer rd.mu.Unlock()
+	upstream, src, err := rd.resolveUpstream(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := upstream.String() + outFormat.cacheSuffix()
 
-	p, ok := rd.cache[r.URL.Path]
-	if !ok {
-		resp, err := rd.client.Get("https://medium.com" + r.URL.Path)
+	entry, err := rd.cache.Fetch(key, func() (*cache.Entry, error) {
+		resp, err := rd.fetch(upstream, src)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		defer resp.Body.Close()
 
+		art, err := readability.Extract(resp.Body, resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if rd.bridge != nil && src != nil {
+			go rd.bridge.Publish(src.Name, art, upstream.String())
+		}
+		if rd.archive != nil {
+			go func() {
+				if _, err := rd.archive.Save(upstream.String(), time.Now(), art); err != nil {
+					log.Printf("archive: save %s: %v", upstream, err)
+				}
+			}()
+		}
+
+		if outFormat != formatHTML {
+			body, err := rd.render(outFormat, art)
+			if err != nil {
+				return nil, err
+			}
+			return &cache.Entry{Code: resp.StatusCode, Body: body}, nil
+		}
+
 		var b bytes.Buffer
 		b.WriteString(`<!doctype html><body>
 <meta name="viewport" content="width=device-width, initial-scale=1">
@@ -64,121 +174,129 @@ h1,h2,h3 { line-height:1.2 }
 img { max-height: 400px; max-width: 400px; }
 </style>
 		`)
-		out, _ := extract(resp.Body)
-		b.Write(out)
-
-		// Poor man's lru ¯\_(ツ)_/¯
-		if len(rd.cache) > 200 {
-			rd.cache = nil
-		}
-		if rd.cache == nil {
-			rd.cache = make(map[string]*page)
+		fmt.Fprintf(&b, "<h1>%s</h1>\n", art.Title)
+		if art.Byline != "" {
+			fmt.Fprintf(&b, "<p><em>%s</em></p>\n", art.Byline)
 		}
-		p = &page{
-			code:    resp.StatusCode,
-			content: b.String(),
-		}
-		rd.cache[r.URL.Path] = p
+		b.WriteString(art.HTML)
+
+		return &cache.Entry{Code: resp.StatusCode, Body: b.Bytes()}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	p.hits++
-	w.Header().Add("x-cache-hits", fmt.Sprint(p.hits))
-	w.Header().Add("x-cache-size", fmt.Sprint(len(rd.cache)))
+	stats := rd.cache.Stats()
+	w.Header().Set("x-cache-hits", fmt.Sprint(atomic.LoadInt64(&entry.Hits)))
+	w.Header().Set("x-cache-size", fmt.Sprint(stats.Entries))
+	w.Header().Set("content-type", outFormat.contentType())
 
-	w.WriteHeader(p.code)
-	io.WriteString(w, p.content)
+	w.WriteHeader(entry.Code)
+	w.Write(entry.Body)
 }
 
-func extract(body io.Reader) ([]byte, error) {
-	var out bytes.Buffer
-
-	var (
-		inArticle    bool
-		discardStack []string
-	)
-
-	z := html.NewTokenizer(body)
-	for {
-		switch z.Next() {
-		case html.ErrorToken:
-			return out.Bytes(), z.Err()
-		case html.TextToken:
-			if inArticle && len(discardStack) == 0 {
-				if _, err := out.Write(z.Text()); err != nil {
-					return out.Bytes(), fmt.Errorf("cannot write text: %w", err)
-				}
+func (rd *readium) serveDebugCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(rd.cache.Stats())
+}
+
+// serveSearch answers /search?q=... with the archived articles whose
+// title or text matches q. It 404s if no archive store is configured.
+func (rd *readium) serveSearch(w http.ResponseWriter, r *http.Request) {
+	if rd.archive == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "missing q parameter", http.StatusBadRequest)
+		return
+	}
+
+	results, err := rd.archive.Search(q, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// resolveUpstream works out which upstream URL and SourceConfig a request
+// maps to. Requests can either address a URL directly, e.g.
+// "/https://example.com/post" (URL-in-path style), or use a source's
+// PathPrefix, e.g. "/medium/some-post" for a source whose PathPrefix is
+// "/medium/". Anything else falls back to the configured default source.
+func (rd *readium) resolveUpstream(r *http.Request) (*url.URL, *SourceConfig, error) {
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/"); ok {
+		if strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://") {
+			if r.URL.RawQuery != "" {
+				rest += "?" + r.URL.RawQuery
 			}
-		case html.SelfClosingTagToken:
-			t, _ := z.TagName()
-			switch tag := string(t); tag {
-			case "br", "img":
-				if inArticle && len(discardStack) == 0 {
-					var attrs []byte
-					for {
-						k, v, more := z.TagAttr()
-						if !more {
-							break
-						}
-						if _, ok := allowedTags[string(k)]; ok {
-							attrs = append(attrs, fmt.Sprintf(`%s="%s"`, k, v)...)
-						}
-					}
-					fmt.Fprintf(&out, "<%s %s>\n", tag, attrs)
-				}
-			default:
+			// The URL-in-path form lets any caller name the upstream
+			// host directly, so it needs the same SSRF guard as a
+			// peer-supplied URL: reject anything that doesn't resolve
+			// to a public address before we ever fetch it.
+			u, err := ssrf.ValidateURL(rest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("upstream url: %w", err)
 			}
-		case html.StartTagToken:
-			t, _ := z.TagName()
-			switch tag := string(t); tag {
-			case "article":
-				inArticle = true
-			case "title", "p", "a", "em", "strong", "div", "span", "section", "h1", "h2", "h3", "blockquote", "figure", "figcaption", "pre", "code":
-				if inArticle && len(discardStack) == 0 {
-					var attrs []byte
-					for {
-						k, v, more := z.TagAttr()
-						if !more {
-							break
-						}
-						if _, ok := allowedTags[string(k)]; ok {
-							attrs = append(attrs, fmt.Sprintf(`%s="%s"`, k, v)...)
-						}
-					}
-					fmt.Fprintf(&out, "<%s %s>\n", tag, attrs)
-				}
-			default:
-				if inArticle {
-					discardStack = append(discardStack, string(tag))
-				}
+			return u, rd.sourceForHost(u.Host), nil
+		}
+	}
+
+	for i := range rd.sources {
+		src := &rd.sources[i]
+		if src.PathPrefix == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(r.URL.Path, src.PathPrefix); ok {
+			path, err := rewritePath(src, "/"+rest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("upstream url: %w", err)
 			}
-		case html.EndTagToken:
-			switch tag, _ := z.TagName(); string(tag) {
-			case "article":
-				inArticle = false
-			case "title", "p", "a", "em", "strong", "div", "span", "section", "h1", "h2", "h3", "blockquote", "figure", "figcaption", "pre", "code":
-				if inArticle && len(discardStack) == 0 {
-					fmt.Fprintf(&out, "</%s>\n", tag)
-				}
-			default:
-				if inArticle {
-					if len(discardStack) == 0 {
-						log.Printf("cannot discard %q: empty stack", tag)
-					} else {
-						if last := discardStack[len(discardStack)-1]; last != string(tag) {
-							log.Printf("cannot discard %q: stack is %q", tag, discardStack)
-						} else {
-							discardStack = discardStack[:len(discardStack)-1]
-						}
-					}
-				}
+			return &url.URL{Scheme: "https", Host: src.Host, Path: path, RawQuery: r.URL.RawQuery}, src, nil
+		}
+	}
+
+	for i := range rd.sources {
+		if rd.sources[i].Default {
+			src := &rd.sources[i]
+			path, err := rewritePath(src, r.URL.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("upstream url: %w", err)
 			}
+			return &url.URL{Scheme: "https", Host: src.Host, Path: path, RawQuery: r.URL.RawQuery}, src, nil
+		}
+	}
+	return nil, nil, errors.New("no source configured for this request")
+}
+
+func (rd *readium) sourceForHost(host string) *SourceConfig {
+	for i := range rd.sources {
+		if rd.sources[i].Host == host {
+			return &rd.sources[i]
 		}
 	}
+	return nil
 }
 
-var allowedTags = map[string]struct{}{
-	"src":   struct{}{},
-	"title": struct{}{},
-	"role":  struct{}{},
-	"href":  struct{}{},
+// fetch issues the upstream GET request, applying the matched source's
+// User-Agent and extra headers, if any.
+func (rd *readium) fetch(u *url.URL, src *SourceConfig) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build upstream request: %w", err)
+	}
+	if src != nil {
+		if src.UserAgent != "" {
+			req.Header.Set("User-Agent", src.UserAgent)
+		}
+		for k, v := range src.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	return rd.client.Do(req)
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveUpstreamRejectsPrivateAndLinkLocalTargets(t *testing.T) {
+	rd := newReadium([]SourceConfig{{Name: "medium", Host: "medium.com", Default: true}}, nil)
+
+	for _, path := range []string{
+		"/http://169.254.169.254/latest/meta-data/",
+		"/http://10.0.0.5/",
+		"/http://192.168.1.1/",
+		"/http://127.0.0.1:6379/",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		if _, _, err := rd.resolveUpstream(req); err == nil {
+			t.Errorf("resolveUpstream(%q): want error, got nil", path)
+		}
+	}
+}
+
+func TestResolveUpstreamAllowsPublicTargets(t *testing.T) {
+	rd := newReadium([]SourceConfig{{Name: "medium", Host: "medium.com", Default: true}}, nil)
+
+	req := httptest.NewRequest("GET", "/https://8.8.8.8/post", nil)
+	u, _, err := rd.resolveUpstream(req)
+	if err != nil {
+		t.Fatalf("resolveUpstream: %v", err)
+	}
+	if u.Host != "8.8.8.8" {
+		t.Errorf("Host = %q, want %q", u.Host, "8.8.8.8")
+	}
+}
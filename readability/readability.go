@@ -0,0 +1,445 @@
+// Package readability extracts the main readable content from an HTML
+// document, loosely following the Arc90 Readability algorithm: score every
+// block-level node, propagate scores upward, and pick the subtree that looks
+// most like an article rather than chrome.
+package readability
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Article is the result of extracting readable content from an HTML
+// document.
+type Article struct {
+	Title        string
+	Byline       string
+	Published    time.Time // zero if the document had no recognizable date
+	CanonicalURL string
+	HTML         string
+	PlainText    string
+	Images       []string // absolute image URLs, in document order
+}
+
+var (
+	positiveWeight = regexp.MustCompile(`(?i)article|content|entry|post|body|main`)
+	negativeWeight = regexp.MustCompile(`(?i)comment|meta|footer|nav|sidebar|share|promo`)
+)
+
+const (
+	// minCandidateTextLen is the shortest text an element anywhere under
+	// the winning candidate (other than <img> and <br>) may have before
+	// it's considered boilerplate and dropped.
+	minCandidateTextLen = 25
+	// maxLinkDensity is the link-to-text ratio above which a node is
+	// considered a link farm (nav, related-posts list, ...) and dropped.
+	maxLinkDensity = 0.5
+)
+
+// Extract parses body as HTML and returns the title, byline and cleaned
+// HTML of its main content. base is used to resolve relative links and
+// image sources found in the extracted content; it may be nil.
+func Extract(body io.Reader, base *url.URL) (*Article, error) {
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+
+	scores := map[*html.Node]float64{}
+	seeded := map[*html.Node]bool{}
+	scoreNode(doc, scores, seeded)
+
+	top := topCandidate(scores)
+	if top == nil {
+		return nil, errors.New("readability: no content candidates found")
+	}
+	scores[top] *= 1 - linkDensity(top)
+
+	prune(top)
+	sanitize(top)
+	resolveLinks(top, base)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, top); err != nil {
+		return nil, fmt.Errorf("render content: %w", err)
+	}
+
+	return &Article{
+		Title:        findTitle(doc),
+		Byline:       findByline(doc),
+		Published:    findPublished(doc),
+		CanonicalURL: findCanonical(doc, base),
+		HTML:         buf.String(),
+		PlainText:    normalizeWhitespace(textContent(top)),
+		Images:       findImages(top),
+	}, nil
+}
+
+// scoreNode walks the tree and scores every <p>, <pre> and <td>, propagating
+// the score to the parent in full and to the grandparent at half weight.
+func scoreNode(n *html.Node, scores map[*html.Node]float64, seeded map[*html.Node]bool) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "p", "pre", "td":
+			text := textContent(n)
+			score := float64(strings.Count(text, ","))
+			if capped := len(text) / 100; capped > 0 {
+				if capped > 3 {
+					capped = 3
+				}
+				score += float64(capped)
+			}
+			if score > 0 {
+				addScore(n.Parent, score, scores, seeded)
+				if n.Parent != nil {
+					addScore(n.Parent.Parent, score/2, scores, seeded)
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		scoreNode(c, scores, seeded)
+	}
+}
+
+// addScore adds score to n's running total, seeding it with its class/id
+// weight the first time it is touched.
+func addScore(n *html.Node, score float64, scores map[*html.Node]float64, seeded map[*html.Node]bool) {
+	if n == nil || n.Type != html.ElementNode {
+		return
+	}
+	if !seeded[n] {
+		scores[n] = classWeight(n)
+		seeded[n] = true
+	}
+	scores[n] += score
+}
+
+func classWeight(n *html.Node) float64 {
+	var w float64
+	for _, a := range n.Attr {
+		if a.Key != "class" && a.Key != "id" {
+			continue
+		}
+		switch {
+		case positiveWeight.MatchString(a.Val):
+			w += 25
+		case negativeWeight.MatchString(a.Val):
+			w -= 25
+		}
+	}
+	return w
+}
+
+func topCandidate(scores map[*html.Node]float64) *html.Node {
+	var best *html.Node
+	var bestScore float64
+	for n, s := range scores {
+		if best == nil || s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	return best
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(textContent(n))
+	if total == 0 {
+		return 0
+	}
+	var linked int
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += len(textContent(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+// prune removes elements at every depth under the winning candidate that
+// look like boilerplate: too short to be real content (inline elements
+// such as a short <a> or <em> inside a paragraph included), or too
+// link-dense.
+func prune(n *html.Node) {
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.Data != "img" && c.Data != "br" && len(textContent(c)) < minCandidateTextLen {
+			n.RemoveChild(c)
+			continue
+		}
+		if linkDensity(c) > maxLinkDensity {
+			n.RemoveChild(c)
+			continue
+		}
+		prune(c)
+	}
+}
+
+// unsafeTags are dropped wholesale from the winning candidate: they carry
+// no readable content, and since Article.HTML ends up served back from
+// readium's own origin, leaving them in would let an upstream page run
+// script (or load arbitrary subresources) in that context.
+var unsafeTags = map[string]bool{
+	"script": true,
+	"style":  true,
+	"iframe": true,
+	"object": true,
+	"embed":  true,
+	"form":   true,
+}
+
+// sanitize strips everything in n that could execute script or load a
+// resource once served from readium's own origin: unsafeTags wholesale,
+// event-handler ("on...") attributes, and javascript:-scheme href/src
+// values. It must run before the subtree is serialized into Article.HTML.
+func sanitize(n *html.Node) {
+	if n.Type == html.ElementNode {
+		stripUnsafeAttrs(n)
+	}
+	var next *html.Node
+	for c := n.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.ElementNode && unsafeTags[c.Data] {
+			n.RemoveChild(c)
+			continue
+		}
+		sanitize(c)
+	}
+}
+
+// stripUnsafeAttrs removes n's event-handler attributes and any
+// javascript:-scheme href/src, in place.
+func stripUnsafeAttrs(n *html.Node) {
+	kept := n.Attr[:0]
+	for _, a := range n.Attr {
+		if strings.HasPrefix(strings.ToLower(a.Key), "on") {
+			continue
+		}
+		if (a.Key == "href" || a.Key == "src") && isJavascriptURL(a.Val) {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	n.Attr = kept
+}
+
+func isJavascriptURL(v string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(v)), "javascript:")
+}
+
+// resolveLinks rewrites relative href/src attributes under n to absolute
+// URLs using base. It is a no-op if base is nil.
+func resolveLinks(n *html.Node, base *url.URL) {
+	if base == nil {
+		return
+	}
+	if n.Type == html.ElementNode {
+		attr := ""
+		switch n.Data {
+		case "a":
+			attr = "href"
+		case "img":
+			attr = "src"
+		}
+		if attr != "" {
+			for i, a := range n.Attr {
+				if a.Key != attr {
+					continue
+				}
+				if u, err := url.Parse(a.Val); err == nil {
+					n.Attr[i].Val = base.ResolveReference(u).String()
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		resolveLinks(c, base)
+	}
+}
+
+func textContent(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+func findTitle(doc *html.Node) string {
+	var title string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if title != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "title" {
+			title = strings.TrimSpace(textContent(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return title
+}
+
+// findCanonical returns the document's <link rel="canonical"> target,
+// resolved against base, falling back to base itself if there isn't one.
+func findCanonical(doc *html.Node, base *url.URL) string {
+	var href string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			isCanonical := false
+			var ref string
+			for _, a := range n.Attr {
+				if a.Key == "rel" && a.Val == "canonical" {
+					isCanonical = true
+				}
+				if a.Key == "href" {
+					ref = a.Val
+				}
+			}
+			if isCanonical && ref != "" {
+				href = ref
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	if href == "" {
+		if base == nil {
+			return ""
+		}
+		return base.String()
+	}
+	u, err := url.Parse(href)
+	if err != nil || base == nil {
+		return href
+	}
+	return base.ResolveReference(u).String()
+}
+
+// findPublished looks for the common article:published_time /
+// datePublished meta tags and parses their RFC 3339 value.
+func findPublished(doc *html.Node) time.Time {
+	var published time.Time
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if !published.IsZero() {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, a := range n.Attr {
+				switch a.Key {
+				case "property", "name", "itemprop":
+					name = a.Val
+				case "content":
+					content = a.Val
+				}
+			}
+			switch name {
+			case "article:published_time", "datePublished", "og:article:published_time":
+				if t, err := time.Parse(time.RFC3339, content); err == nil {
+					published = t
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return published
+}
+
+// findImages collects the (already-resolved) src of every <img> under n,
+// in document order.
+func findImages(n *html.Node) []string {
+	var images []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "img" {
+			for _, a := range n.Attr {
+				if a.Key == "src" && a.Val != "" {
+					images = append(images, a.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return images
+}
+
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func findByline(doc *html.Node) string {
+	var byline string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if byline != "" {
+			return
+		}
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if a.Key == "rel" && a.Val == "author" {
+					byline = strings.TrimSpace(textContent(n))
+					return
+				}
+				if a.Key == "class" && strings.Contains(strings.ToLower(a.Val), "byline") {
+					byline = strings.TrimSpace(textContent(n))
+					return
+				}
+				if a.Key == "class" && strings.Contains(strings.ToLower(a.Val), "author") {
+					byline = strings.TrimSpace(textContent(n))
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return byline
+}
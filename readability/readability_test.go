@@ -0,0 +1,104 @@
+package readability
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractPicksArticleOverChrome(t *testing.T) {
+	const doc = `<!doctype html>
+<html>
+<head><title>A Great Post</title></head>
+<body>
+<nav class="nav"><a href="/a">one</a> <a href="/b">two</a> <a href="/c">three</a></nav>
+<div class="content">
+<p>This is the real article, it has several sentences, with commas, and enough
+length to score well above the navigation noise that surrounds it on the page.</p>
+<p>A second paragraph keeps the content block scoring higher, again with some
+commas, some more words, and plenty of characters to push the length bonus.</p>
+</div>
+<footer class="footer"><a href="/x">x</a></footer>
+</body>
+</html>`
+
+	art, err := Extract(strings.NewReader(doc), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if art.Title != "A Great Post" {
+		t.Errorf("Title = %q, want %q", art.Title, "A Great Post")
+	}
+	if !strings.Contains(art.HTML, "real article") {
+		t.Errorf("HTML does not contain expected article text: %s", art.HTML)
+	}
+	if strings.Contains(art.HTML, "<nav") || strings.Contains(art.HTML, "<footer") {
+		t.Errorf("HTML should not contain the nav/footer chrome: %s", art.HTML)
+	}
+}
+
+func TestExtractStripsScriptsAndEventHandlers(t *testing.T) {
+	const doc = `<!doctype html>
+<html>
+<head><title>A Great Post</title></head>
+<body>
+<div class="content">
+<p>This is the real article, it has several sentences, with commas, and enough
+length to score well above the navigation noise that surrounds it on the page.</p>
+<script>alert(document.cookie)</script>
+<img src="/img/one.png" onerror="alert(1)">
+<a href="javascript:alert(1)">click me</a>
+</div>
+</body>
+</html>`
+
+	art, err := Extract(strings.NewReader(doc), nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if strings.Contains(art.HTML, "<script") || strings.Contains(art.HTML, "alert(document.cookie)") {
+		t.Errorf("HTML should not contain the script tag: %s", art.HTML)
+	}
+	if strings.Contains(art.HTML, "onerror") {
+		t.Errorf("HTML should not contain the onerror attribute: %s", art.HTML)
+	}
+	if strings.Contains(art.HTML, "javascript:") {
+		t.Errorf("HTML should not contain a javascript: href: %s", art.HTML)
+	}
+}
+
+func TestExtractMetadataAndImages(t *testing.T) {
+	const doc = `<!doctype html>
+<html>
+<head>
+<title>A Great Post</title>
+<link rel="canonical" href="/posts/great">
+<meta property="article:published_time" content="2024-03-05T10:00:00Z">
+</head>
+<body>
+<div class="content">
+<p>This is the real article, it has several sentences, with commas, and enough
+length to score well above the navigation noise that surrounds it on the page.</p>
+<img src="/img/one.png" alt="one">
+</div>
+</body>
+</html>`
+
+	base, _ := url.Parse("https://example.com/posts/great")
+	art, err := Extract(strings.NewReader(doc), base)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if art.CanonicalURL != "https://example.com/posts/great" {
+		t.Errorf("CanonicalURL = %q", art.CanonicalURL)
+	}
+	if art.Published.IsZero() {
+		t.Errorf("Published should have been parsed")
+	}
+	if len(art.Images) != 1 || art.Images[0] != "https://example.com/img/one.png" {
+		t.Errorf("Images = %v", art.Images)
+	}
+	if !strings.Contains(art.PlainText, "real article") {
+		t.Errorf("PlainText = %q", art.PlainText)
+	}
+}
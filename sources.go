@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// SourceConfig describes one upstream the proxy is allowed to fetch from:
+// which host it maps to, what path prefix selects it, and any request
+// headers or path rewrites needed to read from it.
+type SourceConfig struct {
+	// Name identifies the source in logs and error messages.
+	Name string `json:"name"`
+	// Host is the upstream host to fetch from, e.g. "medium.com".
+	Host string `json:"host"`
+	// PathPrefix selects this source for incoming requests whose path
+	// starts with it, e.g. "/medium/". It applies to a Default source too.
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Default marks the source used when no PathPrefix matches and the
+	// request path isn't a "/https://..." passthrough URL.
+	Default bool `json:"default,omitempty"`
+	// UserAgent, if set, is sent instead of Go's default.
+	UserAgent string `json:"user_agent,omitempty"`
+	// Headers are added to every upstream request for this source, e.g.
+	// an Authorization header for paywalled sources.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Rewrites are applied in order to the upstream path before it's
+	// fetched, e.g. stripping an AMP suffix some sources require.
+	Rewrites []RewriteRule `json:"rewrites,omitempty"`
+}
+
+// RewriteRule replaces every match of Pattern in the upstream path with
+// Replacement. Pattern is a regular expression in the syntax accepted by
+// package regexp; Replacement may reference capture groups as "$1".
+type RewriteRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// rewritePath applies src's rewrite rules to path in order, compiling each
+// pattern fresh. Source configs are small and loaded once at startup, so
+// there's no need to cache the compiled regexps.
+func rewritePath(src *SourceConfig, path string) (string, error) {
+	if src == nil {
+		return path, nil
+	}
+	for _, rule := range src.Rewrites {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("rewrite rule %q: %w", rule.Pattern, err)
+		}
+		path = re.ReplaceAllString(path, rule.Replacement)
+	}
+	return path, nil
+}
+
+// defaultSources preserves the proxy's original medium.com-only behaviour
+// for installs that don't configure READIUM_SOURCES.
+func defaultSources() []SourceConfig {
+	return []SourceConfig{
+		{Name: "medium", Host: "medium.com", Default: true},
+	}
+}
+
+// loadSources reads a JSON array of SourceConfig from path.
+func loadSources(path string) ([]SourceConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open sources file: %w", err)
+	}
+	defer f.Close()
+
+	var sources []SourceConfig
+	if err := json.NewDecoder(f).Decode(&sources); err != nil {
+		return nil, fmt.Errorf("decode sources file: %w", err)
+	}
+	return sources, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewritePathAppliesRulesInOrder(t *testing.T) {
+	src := &SourceConfig{
+		Rewrites: []RewriteRule{
+			{Pattern: `^/amp/(.*)$`, Replacement: "/$1"},
+			{Pattern: `\.amp$`, Replacement: ""},
+		},
+	}
+
+	got, err := rewritePath(src, "/amp/some-post.amp")
+	if err != nil {
+		t.Fatalf("rewritePath: %v", err)
+	}
+	if want := "/some-post"; got != want {
+		t.Errorf("rewritePath = %q, want %q", got, want)
+	}
+}
+
+func TestRewritePathNilSourceIsNoop(t *testing.T) {
+	got, err := rewritePath(nil, "/some-post")
+	if err != nil {
+		t.Fatalf("rewritePath: %v", err)
+	}
+	if want := "/some-post"; got != want {
+		t.Errorf("rewritePath = %q, want %q", got, want)
+	}
+}
+
+func TestRewritePathInvalidPatternErrors(t *testing.T) {
+	src := &SourceConfig{Rewrites: []RewriteRule{{Pattern: "(", Replacement: ""}}}
+	if _, err := rewritePath(src, "/post"); err == nil {
+		t.Fatal("rewritePath: want error for invalid pattern, got nil")
+	}
+}
+
+func TestResolveUpstreamAppliesSourceRewrites(t *testing.T) {
+	rd := newReadium([]SourceConfig{
+		{
+			Name:       "example",
+			Host:       "example.com",
+			PathPrefix: "/ex/",
+			Rewrites:   []RewriteRule{{Pattern: `\.amp$`, Replacement: ""}},
+		},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/ex/some-post.amp", nil)
+	u, _, err := rd.resolveUpstream(req)
+	if err != nil {
+		t.Fatalf("resolveUpstream: %v", err)
+	}
+	if want := "/some-post"; u.Path != want {
+		t.Errorf("Path = %q, want %q", u.Path, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Package ssrf guards against letting a URL an untrusted caller controls
+// (a request path, a peer's signature keyId, a follower's actor id)
+// induce an outbound request to internal infrastructure.
+package ssrf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ValidateURL parses rawURL and rejects anything unsafe to fetch on
+// behalf of an untrusted caller: non-http(s) schemes, and hosts that
+// resolve to a private, link-local, loopback, multicast, or unspecified
+// address. Loopback is blocked by default, since a request path, a
+// peer's keyId, or an already-extracted image src can all point there
+// just as easily as at an RFC1918 address, and whatever else happens to
+// be bound to loopback on the host (a cache, an admin API, a metrics
+// endpoint) deserves the same protection. Tests that need to talk to
+// their own httptest servers must opt in with AllowLoopbackForTest.
+//
+// This is a cheap up-front rejection for obviously-bad input, not the
+// authoritative guard: the hostname it resolves here can resolve to a
+// different address by the time the real request dials it (an attacker
+// who controls DNS for the host just answers differently the second
+// time). Callers must still issue the request through a client built
+// with Transport, which pins the connection to the address it actually
+// validated.
+func ValidateURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return nil, fmt.Errorf("refusing to fetch from non-public address %s", ip)
+		}
+		return u, nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve host: %w", err)
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return nil, fmt.Errorf("refusing to fetch from non-public address %s", ip)
+		}
+	}
+	return u, nil
+}
+
+// IsPublicIP reports whether ip is routable on the public internet, i.e.
+// not a private, link-local, loopback, multicast or unspecified address.
+// It also accepts loopback while a test has called AllowLoopbackForTest.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return allowLoopback
+	}
+	return !(ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// allowLoopback is flipped on for the duration of a test by
+// AllowLoopbackForTest. It must never be set outside of tests.
+var allowLoopback bool
+
+// testingTB is the subset of *testing.T/*testing.B that AllowLoopbackForTest
+// needs, so this package doesn't have to import "testing" itself.
+type testingTB interface {
+	Helper()
+	Cleanup(func())
+}
+
+// AllowLoopbackForTest makes ValidateURL and Transport accept loopback
+// addresses for the duration of tb's test, restoring the default
+// (loopback blocked) on cleanup. Tests that stand up an httptest server
+// and fetch it through this package need this; production code must
+// never call it.
+func AllowLoopbackForTest(tb testingTB) {
+	tb.Helper()
+	allowLoopback = true
+	tb.Cleanup(func() { allowLoopback = false })
+}
+
+// Transport returns an http.RoundTripper that behaves like
+// http.DefaultTransport, except that it resolves each request's hostname
+// exactly once and dials the specific address it validated, rather than
+// trusting the hostname again at connect time. Plain ValidateURL-then-Do
+// is a check-then-use race: DNS for an attacker-controlled domain can
+// return a public address for the check and a private one (cloud
+// metadata, RFC1918, ...) moments later for the real connection. Pinning
+// the dial to the validated address closes that gap. TLS verification is
+// unaffected, since the transport still negotiates SNI/certificate
+// checks against the original hostname.
+func Transport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialValidated
+	return t
+}
+
+// dialValidated resolves the host in addr, rejects it unless every
+// resolved address is public, and dials the first validated address
+// directly instead of re-resolving addr's hostname.
+func dialValidated(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("split host port: %w", err)
+	}
+
+	var dialer net.Dialer
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return nil, fmt.Errorf("refusing to dial non-public address %s", ip)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve host: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip.IP) {
+			return nil, fmt.Errorf("refusing to dial non-public address %s", ip.IP)
+		}
+	}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+}
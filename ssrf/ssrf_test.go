@@ -0,0 +1,80 @@
+package ssrf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateURLBlocksPrivateAndLinkLocal(t *testing.T) {
+	cases := []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/",
+		"http://192.168.1.1/",
+		"http://172.16.0.1/",
+		"http://127.0.0.1:8080/actor",
+		"http://[::1]/admin",
+		"ftp://example.com/",
+		"file:///etc/passwd",
+	}
+	for _, c := range cases {
+		if _, err := ValidateURL(c); err == nil {
+			t.Errorf("ValidateURL(%q): want error, got nil", c)
+		}
+	}
+}
+
+func TestValidateURLAllowsPublic(t *testing.T) {
+	if _, err := ValidateURL("https://8.8.8.8/post"); err != nil {
+		t.Errorf("ValidateURL: want nil, got %v", err)
+	}
+}
+
+func TestValidateURLAllowsLoopbackWithOptIn(t *testing.T) {
+	AllowLoopbackForTest(t)
+	if _, err := ValidateURL("http://127.0.0.1:8080/actor"); err != nil {
+		t.Errorf("ValidateURL: want nil after AllowLoopbackForTest, got %v", err)
+	}
+}
+
+// TestTransportPinsDialToValidatedAddress guards against the check-then-use
+// gap a plain ValidateURL-then-Do leaves open: even though ValidateURL
+// only inspects the hostname, a client built with Transport must still
+// refuse to connect once the address it actually dials turns out to be
+// non-public, regardless of what the hostname's own DNS records say.
+func TestTransportPinsDialToValidatedAddress(t *testing.T) {
+	if _, err := dialValidated(context.Background(), "tcp", "169.254.169.254:80"); err == nil {
+		t.Fatal("dialValidated: want error dialing a link-local address, got nil")
+	}
+}
+
+func TestTransportRejectsLoopbackByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport()}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("Get: want error dialing loopback by default, got nil")
+	}
+}
+
+func TestTransportAllowsLoopbackWithOptIn(t *testing.T) {
+	AllowLoopbackForTest(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport()}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}